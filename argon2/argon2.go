@@ -3,11 +3,16 @@ package argon2
 
 import (
 	"github.com/pzduniak/argon2"
+	stdargon2 "golang.org/x/crypto/argon2"
 
 	"fmt"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/pzduniak/mcf"
 	"github.com/pzduniak/mcf/bridge"
+	"github.com/pzduniak/mcf/encoder"
 )
 
 // Around 10ms per check on modern low-range server hardware
@@ -17,19 +22,75 @@ const (
 	DefaultIterations  = 8
 	DefaultMemory      = 1024
 	DefaultParallelism = 4
+
+	// DefaultVariant is Argon2id, the current PHC/OWASP recommendation.
+	DefaultVariant = Argon2id
+)
+
+// variantId maps a Variant to the bytes used as the leading "$id" segment of
+// its MCF string. Argon2i keeps the package's original, generic "argon2" id
+// for backward compatibility with hashes stored before Variant existed.
+var variantId = map[Variant][]byte{
+	Argon2i:  []byte("argon2"),
+	Argon2id: []byte("argon2id"),
+	Argon2d:  []byte("argon2d"),
+}
+
+// variantMcfId maps a Variant to the mcf.Id it is registered under.
+var variantMcfId = map[Variant]mcf.Id{
+	Argon2i:  mcf.ARGON2,
+	Argon2id: mcf.ARGON2ID,
+	Argon2d:  mcf.ARGON2D,
+}
+
+// variantRank orders variants for AtLeast comparisons. Argon2id is treated
+// as at-least-as-good as Argon2i since it is a strict improvement for
+// password hashing; Argon2d trades that side-channel resistance for
+// GPU-cracking resistance instead, so it is ranked alongside Argon2i rather
+// than above or below it.
+var variantRank = map[Variant]int{
+	Argon2i:  0,
+	Argon2d:  0,
+	Argon2id: 1,
+}
+
+// Variant selects which Argon2 member function is used to derive the key.
+type Variant string
+
+// Available variants. Argon2id is the current PHC/OWASP recommendation for
+// password hashing, since it mixes Argon2i's side-channel resistance with
+// Argon2d's resistance to GPU cracking. Argon2i and Argon2d remain available
+// for interop with hashes produced by other systems.
+const (
+	Argon2i  Variant = "argon2i"
+	Argon2id Variant = "argon2id"
+	Argon2d  Variant = "argon2d"
 )
 
 // Config contains the argon2 algorithm parameters and other associated values.
 // Use the GetConfig() and SetConfig() combination to change any desired parameters.
 type Config struct {
+	Variant  Variant         // Which Argon2 member function to use
+	Encoding bridge.Encoding // How the bridge renders salt/key bytes in the MCF string
+
 	KeyLen  int // Key output size in bytes
 	SaltLen int // Length of salt in bytes
 
 	Iterations  uint32 // CPU cost
 	Memory      uint32 // Memory cost
 	Parallelism uint32 // Threads to spawn
+
+	// MeasuredLatency is the median single-Key() latency Tune observed while
+	// producing this Config. It is informational only, is not part of
+	// Params()/SetParams(), and is zero on a Config that wasn't built by Tune.
+	MeasuredLatency time.Duration
 }
 
+// DefaultEncoding preserves this package's historical hex-encoded salt/key
+// fields. Set Config.Encoding to bridge.Base64NoPad and friends to
+// interoperate with tools that expect a different layout.
+const DefaultEncoding = bridge.Hex
+
 // Custom source of salt, normally unset.
 // Set this if you need to override the user of rand.Reader and
 // use a custom salt producer.
@@ -52,6 +113,8 @@ func (e ErrInvalidParameter) Error() string {
 // The return value can be modified and used as a parameter to SetConfig
 func GetConfig() Config {
 	return Config{
+		Variant:     DefaultVariant,
+		Encoding:    DefaultEncoding,
 		KeyLen:      DefaultKeyLen,
 		SaltLen:     DefaultSaltLen,
 		Iterations:  DefaultIterations,
@@ -82,44 +145,95 @@ func SetConfig(config Config) error {
 }
 
 func register(config Config) error {
+	id, ok := variantId[config.Variant]
+	if !ok {
+		return ErrInvalidVariant(config.Variant)
+	}
+
 	// Constructor function. Provide fresh copy each time.
 	fn := func() bridge.Implementer {
 		c := config
 		return &c
 	}
 
-	enc := bridge.New([]byte("argon2"), fn)
+	var enc encoder.Encoder = bridge.New(id, fn)
+	if phcId, ok := phcVariantId[config.Variant]; ok && phcId == string(id) {
+		// Argon2id and Argon2d's PHC id is identical to their bridge-native
+		// id, so both formats have to share this one registration; see
+		// dualFormatEncoder in phc.go.
+		enc = dualFormatEncoder{native: enc, phc: phcCodec{variant: config.Variant}}
+	}
 
-	return mcf.Register(mcf.ARGON2, enc)
+	return mcf.Register(variantMcfId[config.Variant], enc)
 }
 
 func init() {
-	err := register(GetConfig())
-	if err != nil {
-		panic(err)
+	// Register a verifier for every variant, so hashes produced by any of
+	// them can still be checked even after SetConfig changes the default
+	// used for new Generate calls.
+	for variant := range variantId {
+		config := GetConfig()
+		config.Variant = variant
+		if err := register(config); err != nil {
+			panic(err)
+		}
 	}
 }
 
+// ErrInvalidVariant is returned by SetConfig or SetParams when the Config's
+// Variant is not one of Argon2i, Argon2id or Argon2d.
+type ErrInvalidVariant Variant
+
+func (e ErrInvalidVariant) Error() string {
+	return fmt.Sprintf("argon2: invalid variant: %q", string(e))
+}
+
 func (c *Config) validate() error {
-	//punt, cheat and see if the underlying algorithm complains!
-	_, err := c.Key([]byte("password"), []byte("salt"))
+	if _, ok := variantId[c.Variant]; !ok {
+		return ErrInvalidVariant(c.Variant)
+	}
+
+	//punt, cheat and see if the underlying algorithm complains! The salt
+	//literal has to satisfy argon2's own minimum length (8 bytes), same as
+	//scrypt's validate() already uses a 16-byte one.
+	_, err := c.Key([]byte("password"), []byte("saltsaltsaltsalt"))
 	return err
 }
 
 // Keep these together.
-var format = "KeyLen=%d,I=%d,M=%d,P=%d"
+// Note that Sscanf's %s reads to the end of input and must therefore be the
+// last (and only) string directive; Variant and Encoding are packed into
+// that single trailing field, comma-separated, and split out by hand below.
+var format = "KeyLen=%d,I=%d,M=%d,P=%d,VE=%s"
 
 // Params returns the current digest algorithm parameters.
 func (c *Config) Params() string {
-	return fmt.Sprintf(format, c.KeyLen, c.Iterations, c.Memory, c.Parallelism)
+	return fmt.Sprintf(format, c.KeyLen, c.Iterations, c.Memory, c.Parallelism, fmt.Sprintf("%s,%s", c.Variant, c.Encoding))
 }
 
 // SetParams sets the parameters for the digest algorithm.
 func (c *Config) SetParams(s string) error {
-	_, err := fmt.Sscanf(s, format, &c.KeyLen, &c.Iterations, &c.Memory, &c.Parallelism)
+	var ve string
+	_, err := fmt.Sscanf(s, format, &c.KeyLen, &c.Iterations, &c.Memory, &c.Parallelism, &ve)
 	if err != nil {
-		return err
+		// Hashes stored before Variant and Encoding existed have no VE
+		// field; they are always Argon2i with hex salt/key.
+		if strings.Contains(s, ",VE=") {
+			return err
+		}
+		if _, lerr := fmt.Sscanf(s, "KeyLen=%d,I=%d,M=%d,P=%d", &c.KeyLen, &c.Iterations, &c.Memory, &c.Parallelism); lerr != nil {
+			return err
+		}
+		ve = fmt.Sprintf("%s,%s", Argon2i, bridge.Hex)
+	}
+
+	parts := strings.SplitN(ve, ",", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("argon2: malformed variant/encoding field: %q", ve)
 	}
+	c.Variant = Variant(parts[0])
+	c.Encoding = bridge.Encoding(parts[1])
+
 	return c.validate()
 }
 
@@ -128,15 +242,53 @@ func (c *Config) Salt() ([]byte, error) {
 	return mcf.Salt(c.SaltLen, SaltMine)
 }
 
+// EncodingMode reports how the bridge should render this Config's salt and
+// key bytes; see bridge.Implementer.EncodingMode for why it's read per-instance.
+func (c *Config) EncodingMode() bridge.Encoding {
+	return c.Encoding
+}
+
 // Key returns an argon2 digest of password and salt using the algorithm parameters: N, r, and p.
 // The returned value is of length KeyLen.
+// If mcf.ZeroizePlaintext() is set, plaintext is wiped before Key returns.
 func (c *Config) Key(plaintext []byte, salt []byte) (b []byte, err error) {
-	return argon2.Key(plaintext, salt, c.Iterations, c.Parallelism, c.Memory, c.KeyLen, argon2.Argon2i)
+	switch c.Variant {
+	case Argon2id:
+		// github.com/pzduniak/argon2 (used below for Argon2i/Argon2d) has no
+		// Argon2id mode; golang.org/x/crypto/argon2 is the real upstream
+		// implementation of it. IDKey has no error return and panics on
+		// parameters it can't use, so those are rejected here first.
+		switch {
+		case c.Iterations < 1:
+			err = ErrInvalidParameter{Name: "Iterations", Value: int(c.Iterations)}
+		case c.Parallelism < 1 || c.Parallelism > 255:
+			err = ErrInvalidParameter{Name: "Parallelism", Value: int(c.Parallelism)}
+		case c.KeyLen < 1:
+			err = ErrInvalidParameter{Name: "KeyLen", Value: c.KeyLen}
+		default:
+			b = stdargon2.IDKey(plaintext, salt, c.Iterations, c.Memory, uint8(c.Parallelism), uint32(c.KeyLen))
+		}
+	case Argon2d:
+		b, err = argon2.Key(plaintext, salt, c.Iterations, c.Parallelism, c.Memory, c.KeyLen, argon2.Argon2d)
+	default: // Argon2i
+		b, err = argon2.Key(plaintext, salt, c.Iterations, c.Parallelism, c.Memory, c.KeyLen, argon2.Argon2i)
+	}
+
+	if mcf.ZeroizePlaintext() {
+		bridge.Wipe(plaintext)
+	}
+	runtime.KeepAlive(plaintext)
+
+	return b, err
 }
 
 // AtLeast returns true if the parameters used to generate the encoded password
 // are at least as good as those currently in use.
 func (c *Config) AtLeast(current_imp bridge.Implementer) bool {
 	current := current_imp.(*Config) // ok to panic
-	return !(c.Iterations < current.Iterations || c.Memory < current.Memory || c.Parallelism < current.Parallelism || c.KeyLen < current.KeyLen)
+	return !(c.Iterations < current.Iterations ||
+		c.Memory < current.Memory ||
+		c.Parallelism < current.Parallelism ||
+		c.KeyLen < current.KeyLen ||
+		variantRank[c.Variant] < variantRank[current.Variant])
 }
@@ -0,0 +1,78 @@
+package argon2
+
+import "testing"
+
+// TestVariantsKey covers Argon2id being derived through
+// golang.org/x/crypto/argon2 (github.com/pzduniak/argon2, used for Argon2i
+// and Argon2d, has no Argon2id mode at all) and the validate() punt salt
+// meeting every variant's minimum salt length.
+func TestVariantsKey(t *testing.T) {
+	for _, variant := range []Variant{Argon2i, Argon2id, Argon2d} {
+		variant := variant
+		t.Run(string(variant), func(t *testing.T) {
+			c := GetConfig()
+			c.Variant = variant
+
+			if err := c.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+
+			salt, err := c.Salt()
+			if err != nil {
+				t.Fatalf("Salt: %v", err)
+			}
+
+			key, err := c.Key([]byte("hunter2"), salt)
+			if err != nil {
+				t.Fatalf("Key: %v", err)
+			}
+			if len(key) != c.KeyLen {
+				t.Fatalf("Key returned %d bytes, want %d", len(key), c.KeyLen)
+			}
+		})
+	}
+}
+
+// TestPHCRoundTrip exercises dualFormatEncoder's format detection for
+// Argon2id, whose PHC id ("argon2id") is identical to its bridge-native id
+// and so shares a single mcf.Register call with it instead of two.
+func TestPHCRoundTrip(t *testing.T) {
+	c := GetConfig()
+	c.Variant = Argon2id
+
+	salt, err := c.Salt()
+	if err != nil {
+		t.Fatalf("Salt: %v", err)
+	}
+	key, err := c.Key([]byte("hunter2"), salt)
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+
+	phc, err := c.MarshalPHC(salt, key)
+	if err != nil {
+		t.Fatalf("MarshalPHC: %v", err)
+	}
+
+	if !isPHCEncoded([]byte(phc)) {
+		t.Fatal("isPHCEncoded didn't recognize a string produced by MarshalPHC")
+	}
+
+	codec := phcCodec{variant: Argon2id}
+
+	ok, err := codec.Verify([]byte("hunter2"), []byte(phc))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Fatal("Verify returned false for a hash just generated with the same password")
+	}
+
+	ok, err = codec.Verify([]byte("wrong"), []byte(phc))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if ok {
+		t.Fatal("Verify returned true for the wrong password")
+	}
+}
@@ -0,0 +1,241 @@
+package argon2
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pzduniak/mcf"
+	"github.com/pzduniak/mcf/bridge"
+	"github.com/pzduniak/mcf/encoder"
+)
+
+// PHCVersion is the Argon2 version number embedded in the "v=" field of a
+// PHC-formatted hash. This package only ever produces version 19 (0x13) hashes.
+const PHCVersion = 19
+
+// phcVariantId maps a Variant to the PHC algorithm identifier used as the
+// leading "$id" segment, e.g. "argon2id". Unlike variantId, this always
+// spells the variant out in full, since the PHC spec has no generic
+// "argon2" id to fall back on.
+var phcVariantId = map[Variant]string{
+	Argon2i:  "argon2i",
+	Argon2id: "argon2id",
+	Argon2d:  "argon2d",
+}
+
+var phcIdVariant = func() map[string]Variant {
+	m := make(map[string]Variant, len(phcVariantId))
+	for variant, id := range phcVariantId {
+		m[id] = variant
+	}
+	return m
+}()
+
+var phcEncoding = base64.RawStdEncoding
+
+// MarshalPHC encodes salt and key as a PHC string
+// (https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md), e.g.
+//
+//	$argon2id$v=19$m=1024,t=8,p=4$<b64salt>$<b64hash>
+//
+// This is the format used by passlib, passwap, kdfcrypt and most other
+// ecosystem tools, as opposed to this package's historical Params()/SetParams()
+// layout. It returns an error if c.Variant isn't one of Argon2i/Argon2id/Argon2d.
+func (c *Config) MarshalPHC(salt, key []byte) (string, error) {
+	id, ok := phcVariantId[c.Variant]
+	if !ok {
+		return "", ErrInvalidVariant(c.Variant)
+	}
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		id, PHCVersion, c.Memory, c.Iterations, c.Parallelism,
+		phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+// UnmarshalPHC parses a PHC-formatted argon2 hash, returning the parameters
+// it encodes along with the raw salt and key bytes. It returns an error if s
+// is not a well-formed PHC string or uses an id this package doesn't recognize.
+func UnmarshalPHC(s string) (c Config, salt, key []byte, err error) {
+	parts := strings.Split(s, "$")
+	// strings.Split("$argon2id$...", "$") yields a leading empty element.
+	if len(parts) != 6 || parts[0] != "" {
+		return c, nil, nil, fmt.Errorf("argon2: not a PHC string")
+	}
+
+	variant, ok := phcIdVariant[parts[1]]
+	if !ok {
+		return c, nil, nil, fmt.Errorf("argon2: unrecognized PHC id: %s", parts[1])
+	}
+	c.Variant = variant
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return c, nil, nil, err
+	}
+	if version != PHCVersion {
+		return c, nil, nil, fmt.Errorf("argon2: unsupported PHC version: %d", version)
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &c.Memory, &c.Iterations, &c.Parallelism); err != nil {
+		return c, nil, nil, err
+	}
+
+	if salt, err = phcEncoding.DecodeString(parts[4]); err != nil {
+		return c, nil, nil, err
+	}
+	if key, err = phcEncoding.DecodeString(parts[5]); err != nil {
+		return c, nil, nil, err
+	}
+
+	c.SaltLen = len(salt)
+	c.KeyLen = len(key)
+
+	return c, salt, key, nil
+}
+
+// phcCodec is an encoder.Encoder that reads and writes the PHC string format
+// on top of this package's Config, so PHC-encoded hashes can be registered
+// with mcf.Register alongside the package's historical format.
+type phcCodec struct {
+	variant Variant
+}
+
+func (p phcCodec) Id() []byte {
+	return []byte(phcVariantId[p.variant])
+}
+
+func (p phcCodec) Generate(plaintext []byte) ([]byte, error) {
+	c := GetConfig()
+	c.Variant = p.variant
+
+	salt, err := c.Salt()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.Key(plaintext, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer bridge.Wipe(key)
+
+	s, err := c.MarshalPHC(salt, key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func (phcCodec) Verify(plaintext, encoded []byte) (bool, error) {
+	c, salt, key, err := UnmarshalPHC(string(encoded))
+	if err != nil {
+		return false, err
+	}
+
+	candidate, err := c.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+	defer bridge.Wipe(candidate)
+
+	return len(candidate) == len(key) && subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (phcCodec) IsCurrent(encoded []byte) (bool, error) {
+	c, _, _, err := UnmarshalPHC(string(encoded))
+	if err != nil {
+		return false, err
+	}
+
+	current := GetConfig()
+	current.Variant = c.Variant
+	return c.AtLeast(&current), nil
+}
+
+var _ encoder.Encoder = phcCodec{}
+
+// RegisterPHC registers the PHC string format for variant under its own PHC
+// id (e.g. mcf.Id("argon2id")), so that mcf.Generate/mcf.Verify can produce
+// and consume "$argon2id$v=19$m=...,t=...,p=...$<salt>$<hash>" strings
+// interoperable with passlib, passwap and kdfcrypt, in addition to this
+// package's own format registered under mcf.ARGON2/mcf.ARGON2ID/mcf.ARGON2D.
+//
+// Only call this for a variant whose PHC id differs from its bridge-native
+// id (currently just Argon2i, "argon2i" vs "argon2"); Argon2id and Argon2d's
+// PHC id is identical to their bridge-native id, and register() in
+// argon2.go already wires PHC support for those two in via dualFormatEncoder
+// instead, since mcf.Register only allows one encoder per id.
+func RegisterPHC(variant Variant) error {
+	id, ok := phcVariantId[variant]
+	if !ok {
+		return ErrInvalidVariant(variant)
+	}
+	return mcf.Register(mcf.Id(id), phcCodec{variant: variant})
+}
+
+func init() {
+	// Argon2id and Argon2d are skipped here: their PHC id is the exact same
+	// string as their bridge-native id (see variantId/phcVariantId), so
+	// mcf.Register would panic on the second, duplicate registration.
+	// argon2.go's register() already gives those two ids dual-format
+	// support. Only Argon2i's PHC id ("argon2i") is distinct from its
+	// bridge-native id ("argon2"), so it's the only one registered here.
+	for variant, phcId := range phcVariantId {
+		if phcId == string(variantId[variant]) {
+			continue
+		}
+		if err := RegisterPHC(variant); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// dualFormatEncoder lets a single mcf.Id accept either this package's
+// historical bridge-native format or the PHC format. It exists because
+// Argon2id and Argon2d's phcVariantId happens to equal their bridge-native
+// variantId, so only one encoder can ever be registered for that id, and it
+// has to recognize both of this package's wire formats to stay
+// interoperable with both.
+type dualFormatEncoder struct {
+	native encoder.Encoder
+	phc    phcCodec
+}
+
+func (d dualFormatEncoder) Id() []byte {
+	return d.native.Id()
+}
+
+// Generate always produces this package's historical bridge-native format,
+// leaving PHC output to argon2.RegisterPHC's own id for variants where the
+// two don't collide (Argon2i), so SetConfig/Generate behavior here is
+// unchanged by PHC support existing at all.
+func (d dualFormatEncoder) Generate(plaintext []byte) ([]byte, error) {
+	return d.native.Generate(plaintext)
+}
+
+// isPHCEncoded tells the PHC format apart from this package's bridge-native
+// format by field count: PHC has an extra "v=19" field
+// ("$id$v=19$m=...,t=...,p=...$salt$key", 5 "$" separators) that the
+// bridge-native format doesn't ("$id$KeyLen=...,...$salt$key", 4).
+func isPHCEncoded(encoded []byte) bool {
+	return bytes.Count(encoded, []byte("$")) == 5
+}
+
+func (d dualFormatEncoder) Verify(plaintext, encoded []byte) (bool, error) {
+	if isPHCEncoded(encoded) {
+		return d.phc.Verify(plaintext, encoded)
+	}
+	return d.native.Verify(plaintext, encoded)
+}
+
+func (d dualFormatEncoder) IsCurrent(encoded []byte) (bool, error) {
+	if isPHCEncoded(encoded) {
+		return d.phc.IsCurrent(encoded)
+	}
+	return d.native.IsCurrent(encoded)
+}
+
+var _ encoder.Encoder = dualFormatEncoder{}
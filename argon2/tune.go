@@ -0,0 +1,149 @@
+package argon2
+
+import (
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Suggested targets for Tune. Interactive logins should feel instant;
+// batch/offline verification (e.g. importing a password database) can
+// afford to spend much longer per check.
+const (
+	DefaultInteractiveTarget = 250 * time.Millisecond
+	DefaultBatchTarget       = time.Second
+)
+
+// DefaultTuneMemoryCap is the Memory (KiB) Tune will grow up to before
+// giving up on Memory and bisecting Iterations instead. 4 GiB.
+const DefaultTuneMemoryCap uint32 = 4 * 1024 * 1024
+
+// tuneSamples is how many probes Tune takes at each candidate parameter
+// value; the median of these is used to dampen scheduler noise.
+const tuneSamples = 5
+
+// Tune benchmarks Config.Key on the current machine and returns a Config
+// whose parameters make a single password check take about target.
+//
+// Parallelism is held at runtime.NumCPU() and KeyLen/SaltLen at their
+// defaults. Memory is grown by doubling, with Iterations held at 1, until
+// either target is reached or DefaultTuneMemoryCap is hit; it is then
+// bisected against the last two probed values to land close to target. If
+// the cap is reached before target, Memory is held at the cap and
+// Iterations is doubled-then-bisected instead. The returned Config's
+// MeasuredLatency field records the latency actually observed.
+func Tune(target time.Duration) (Config, error) {
+	return TuneWithCap(target, DefaultTuneMemoryCap)
+}
+
+// TuneWithCap is Tune with a caller-supplied Memory cap, in KiB.
+func TuneWithCap(target time.Duration, memoryCap uint32) (Config, error) {
+	c := GetConfig()
+	c.Parallelism = uint32(runtime.NumCPU())
+	c.Iterations = 1
+
+	lo, hi := uint32(0), c.Memory
+
+	latency, err := medianLatency(&c, tuneSamples)
+	if err != nil {
+		return c, err
+	}
+
+	for latency < target && hi < memoryCap {
+		lo = hi
+		hi *= 2
+		if hi > memoryCap {
+			hi = memoryCap
+		}
+		c.Memory = hi
+
+		latency, err = medianLatency(&c, tuneSamples)
+		if err != nil {
+			return c, err
+		}
+	}
+
+	if latency < target {
+		// Memory capped out before reaching target; hold it and bisect
+		// Iterations instead.
+		c.Memory = memoryCap
+
+		iLo, iHi := uint32(1), uint32(2)
+		c.Iterations = iHi
+		latency, err = medianLatency(&c, tuneSamples)
+		if err != nil {
+			return c, err
+		}
+
+		for latency < target {
+			iLo = iHi
+			iHi *= 2
+			c.Iterations = iHi
+
+			latency, err = medianLatency(&c, tuneSamples)
+			if err != nil {
+				return c, err
+			}
+		}
+
+		for iHi-iLo > 1 {
+			mid := iLo + (iHi-iLo)/2
+			c.Iterations = mid
+
+			latency, err = medianLatency(&c, tuneSamples)
+			if err != nil {
+				return c, err
+			}
+
+			if latency < target {
+				iLo = mid
+			} else {
+				iHi = mid
+			}
+		}
+		c.Iterations = iHi
+	} else {
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			c.Memory = mid
+
+			latency, err = medianLatency(&c, tuneSamples)
+			if err != nil {
+				return c, err
+			}
+
+			if latency < target {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		c.Memory = hi
+	}
+
+	latency, err = medianLatency(&c, tuneSamples)
+	if err != nil {
+		return c, err
+	}
+	c.MeasuredLatency = latency
+
+	return c, nil
+}
+
+// medianLatency runs c.Key samples times against a fixed password/salt and
+// returns the median wall-clock duration of a single call.
+func medianLatency(c *Config, samples int) (time.Duration, error) {
+	salt := make([]byte, c.SaltLen)
+	durations := make([]time.Duration, 0, samples)
+
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if _, err := c.Key([]byte("password"), salt); err != nil {
+			return 0, err
+		}
+		durations = append(durations, time.Since(start))
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2], nil
+}
@@ -0,0 +1,113 @@
+// Package bcrypt implements a password encoding mechanism for the mcf
+// framework using golang.org/x/crypto/bcrypt directly. bcrypt hashes embed
+// their own salt and cost, so unlike argon2, pbkdf2 or scrypt this package
+// doesn't go through the bridge package, whose Implementer model assumes an
+// externally supplied salt and a parameters string kept separate from it.
+//
+// This package registers under mcf.BCRYPT ("2a"), the literal id
+// golang.org/x/crypto/bcrypt.GenerateFromPassword embeds in every hash it
+// produces. That collides with migrations.BCryptA if the migrations
+// package is imported too: only import both if you don't mind that package
+// losing its claim on "2a" hashes (and, with it, always treating them as
+// legacy and rehashing on next login).
+package bcrypt
+
+import (
+	"golang.org/x/crypto/bcrypt"
+
+	"fmt"
+
+	"github.com/pzduniak/mcf"
+	"github.com/pzduniak/mcf/encoder"
+)
+
+// DefaultCost is bcrypt's own default cost factor.
+const DefaultCost = bcrypt.DefaultCost
+
+// Config contains the bcrypt algorithm parameters.
+// Use the GetConfig() and SetConfig() combination to change any desired parameters.
+type Config struct {
+	Cost int // CPU cost factor
+}
+
+// ErrInvalidParameter is returned by SetConfig if any of the provided parameters
+// fail validation. The error message contains the name and value of the faulty
+// parameter to aid in resolving the problem.
+type ErrInvalidParameter struct {
+	Name  string
+	Value int
+}
+
+func (e ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("parameter %s has invalid value: %d", e.Name, e.Value)
+}
+
+// GetConfig returns the default configuration used to create new bcrypt password hashes.
+// The return value can be modified and used as a parameter to SetConfig.
+func GetConfig() Config {
+	return Config{Cost: DefaultCost}
+}
+
+// SetConfig sets the default cost factor.
+//
+//	config := bcrypt.GetConfig()
+//	config.Cost++
+//	bcrypt.SetConfig(config)
+func SetConfig(config Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	return register(config)
+}
+
+func (c Config) validate() error {
+	if c.Cost < bcrypt.MinCost || c.Cost > bcrypt.MaxCost {
+		return ErrInvalidParameter{Name: "Cost", Value: c.Cost}
+	}
+	return nil
+}
+
+// bcryptEncoder implements encoder.Encoder directly instead of going
+// through bridge: GenerateFromPassword generates and embeds its own salt,
+// and CompareHashAndPassword/Cost parse the whole "$2a$cost$salt+hash"
+// string themselves rather than taking a separately supplied salt and
+// parameters string the way bridge.Implementer expects.
+type bcryptEncoder struct {
+	cost int
+}
+
+func (b bcryptEncoder) Id() []byte {
+	return []byte(mcf.BCRYPT)
+}
+
+func (b bcryptEncoder) Generate(plaintext []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(plaintext, b.cost)
+}
+
+func (b bcryptEncoder) Verify(plaintext, encoded []byte) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(encoded, plaintext)
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b bcryptEncoder) IsCurrent(encoded []byte) (bool, error) {
+	cost, err := bcrypt.Cost(encoded)
+	if err != nil {
+		return false, err
+	}
+	return cost >= b.cost, nil
+}
+
+var _ encoder.Encoder = bcryptEncoder{}
+
+func register(config Config) error {
+	return mcf.Register(mcf.BCRYPT, bcryptEncoder{cost: config.Cost})
+}
+
+func init() {
+	if err := register(GetConfig()); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,56 @@
+package bcrypt
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestGenerateVerify(t *testing.T) {
+	enc := bcryptEncoder{cost: bcrypt.MinCost}
+
+	encoded, err := enc.Generate([]byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	ok, err := enc.Verify([]byte("hunter2"), encoded)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Fatal("Verify returned false for a hash just generated with the same password")
+	}
+
+	ok, err = enc.Verify([]byte("wrong"), encoded)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if ok {
+		t.Fatal("Verify returned true for the wrong password")
+	}
+}
+
+func TestIsCurrent(t *testing.T) {
+	low := bcryptEncoder{cost: bcrypt.MinCost}
+	encoded, err := low.Generate([]byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	cur, err := low.IsCurrent(encoded)
+	if err != nil {
+		t.Fatalf("IsCurrent: %v", err)
+	}
+	if !cur {
+		t.Fatal("IsCurrent false for a hash at the encoder's own cost")
+	}
+
+	high := bcryptEncoder{cost: bcrypt.MinCost + 1}
+	cur, err = high.IsCurrent(encoded)
+	if err != nil {
+		t.Fatalf("IsCurrent: %v", err)
+	}
+	if cur {
+		t.Fatal("IsCurrent true for a hash generated at a lower cost than the encoder now uses")
+	}
+}
@@ -0,0 +1,209 @@
+// Package bridge implements the generic parts of an encoder.Encoder —
+// assembling and parsing the "$id$params$salt$key" MCF string, sourcing
+// salt, and comparing keys — so an algorithm package only has to implement
+// Implementer with its own parameters and key-derivation function.
+package bridge
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/pzduniak/mcf/encoder"
+)
+
+// Encoding controls how a bridge-backed encoder renders salt and key bytes
+// within the MCF string.
+type Encoding string
+
+// Available encodings.
+const (
+	Base64Std   Encoding = "b64"
+	Base64URL   Encoding = "b64url"
+	Base64NoPad Encoding = "b64raw"
+	Hex         Encoding = "hex"
+	UTF8Raw     Encoding = "raw"
+)
+
+// Implementer is the algorithm-specific half of a bridge-backed
+// encoder.Encoder. A Config type (such as argon2.Config or pbkdf2.Config)
+// implements this to plug its parameters, salt source and key derivation
+// into the bridge.
+type Implementer interface {
+	// Params encodes the algorithm's parameters, excluding salt and key, as
+	// a string containing no "$" characters.
+	Params() string
+
+	// SetParams decodes a string previously returned by Params.
+	SetParams(string) error
+
+	// Salt produces fresh salt bytes for a new hash.
+	Salt() ([]byte, error)
+
+	// Key derives a key from plaintext and salt using the parameters
+	// currently held by the Implementer.
+	Key(plaintext, salt []byte) ([]byte, error)
+
+	// AtLeast reports whether this Implementer's parameters are at least as
+	// strong as current's.
+	AtLeast(current Implementer) bool
+
+	// EncodingMode reports how this particular Implementer's salt and key
+	// bytes should be rendered. It is read off the Implementer itself —
+	// after SetParams has decoded a stored hash's own Encoding field, if the
+	// algorithm package's Config carries one — so a hash keeps being
+	// readable under the encoding it was written with even after a later
+	// SetConfig picks a different one for new hashes. (Named EncodingMode,
+	// not Encoding, so it doesn't collide with a Config's own exported
+	// Encoding field.)
+	EncodingMode() Encoding
+}
+
+// Encode renders b using e, or panics if e is not one of the Encoding
+// constants. The bridge's Generate checks an Implementer's EncodingMode
+// before calling Encode and returns an error for an unknown one instead.
+func (e Encoding) Encode(b []byte) string {
+	switch e {
+	case Base64Std:
+		return base64Std.EncodeToString(b)
+	case Base64URL:
+		return base64URL.EncodeToString(b)
+	case Base64NoPad:
+		return base64Raw.EncodeToString(b)
+	case Hex:
+		return hexEncode(b)
+	case UTF8Raw:
+		return string(b)
+	default:
+		panic(fmt.Sprintf("bridge: unknown encoding: %q", e))
+	}
+}
+
+// Decode parses s, previously produced by Encode with the same Encoding.
+func (e Encoding) Decode(s string) ([]byte, error) {
+	switch e {
+	case Base64Std:
+		return base64Std.DecodeString(s)
+	case Base64URL:
+		return base64URL.DecodeString(s)
+	case Base64NoPad:
+		return base64Raw.DecodeString(s)
+	case Hex:
+		return hexDecode(s)
+	case UTF8Raw:
+		return []byte(s), nil
+	default:
+		return nil, fmt.Errorf("bridge: unknown encoding: %q", e)
+	}
+}
+
+// bridgeEncoder is the encoder.Encoder New returns.
+type bridgeEncoder struct {
+	id []byte
+	fn func() Implementer
+}
+
+// New returns an encoder.Encoder that assembles and parses MCF strings as
+// "$id$params$salt$key", deferring parameters, salt and key derivation to
+// the Implementer fn produces. fn must return a fresh Implementer on every
+// call, since the bridge mutates it via SetParams while verifying a stored
+// hash.
+func New(id []byte, fn func() Implementer) encoder.Encoder {
+	return bridgeEncoder{id: id, fn: fn}
+}
+
+func (b bridgeEncoder) Id() []byte {
+	return b.id
+}
+
+func (b bridgeEncoder) Generate(plaintext []byte) ([]byte, error) {
+	impl := b.fn()
+
+	if _, err := impl.EncodingMode().Decode(""); err != nil {
+		return nil, err
+	}
+
+	salt, err := impl.Salt()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := impl.Key(plaintext, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(key)
+
+	s := fmt.Sprintf("$%s$%s$%s$%s", b.id, impl.Params(),
+		impl.EncodingMode().Encode(salt), impl.EncodingMode().Encode(key))
+
+	return []byte(s), nil
+}
+
+// Wipe overwrites b with zeros in place. The bridge and the PHC codecs defer
+// it on derived keys; argon2's and pbkdf2's Key call it on the caller's
+// plaintext and pair it with runtime.KeepAlive at the call site, so the
+// compiler can't prove the writes are dead and elide them.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// split breaks an MCF string of the form "$id$params$salt$key" into its
+// four fields.
+func split(encoded []byte) (id, params, salt, key string, err error) {
+	parts := strings.Split(string(encoded), "$")
+	// strings.Split("$id$...", "$") yields a leading empty element.
+	if len(parts) != 5 || parts[0] != "" {
+		return "", "", "", "", fmt.Errorf("bridge: not an mcf-encoded string: %q", encoded)
+	}
+	return parts[1], parts[2], parts[3], parts[4], nil
+}
+
+func (b bridgeEncoder) Verify(plaintext, encoded []byte) (bool, error) {
+	_, params, saltField, keyField, err := split(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	impl := b.fn()
+	if err := impl.SetParams(params); err != nil {
+		return false, err
+	}
+
+	salt, err := impl.EncodingMode().Decode(saltField)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := impl.EncodingMode().Decode(keyField)
+	if err != nil {
+		return false, err
+	}
+
+	candidate, err := impl.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+	defer Wipe(candidate)
+
+	return len(candidate) == len(key) && subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (b bridgeEncoder) IsCurrent(encoded []byte) (bool, error) {
+	_, params, _, _, err := split(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	stored := b.fn()
+	if err := stored.SetParams(params); err != nil {
+		return false, err
+	}
+
+	current := b.fn()
+	return stored.AtLeast(current), nil
+}
+
+var _ encoder.Encoder = bridgeEncoder{}
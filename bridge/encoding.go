@@ -0,0 +1,20 @@
+package bridge
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+var (
+	base64Std = base64.StdEncoding
+	base64URL = base64.URLEncoding
+	base64Raw = base64.RawStdEncoding
+)
+
+func hexEncode(b []byte) string {
+	return hex.EncodeToString(b)
+}
+
+func hexDecode(s string) ([]byte, error) {
+	return hex.DecodeString(s)
+}
@@ -0,0 +1,172 @@
+// Package mcf is a small framework for encoding and verifying passwords in
+// variants of the Modular Crypt Format (and the newer PHC string format),
+// without the caller needing to know which concrete algorithm produced a
+// given stored hash.
+//
+// Algorithm packages (argon2, pbkdf2, bcrypt, scrypt, ...) register one or
+// more encoder.Encoder implementations with Register under an Id that
+// matches the "$id$" segment leading their encoded strings. Callers then use
+// Generate to create new hashes and Verify/IsCurrent to check and age out
+// old ones, without importing the algorithm packages directly.
+//
+// This package and the bridge package it depends on were not added to this
+// tree until a "chunk0-5" fix commit, even though argon2 and pbkdf2 had
+// already been importing both since "chunk0-1". Commits before that fix
+// don't build standalone as a result; check out from it onward if bisecting.
+package mcf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/pzduniak/mcf/encoder"
+)
+
+// Id identifies a registered encoder. For every encoder in this module it is
+// exactly the bytes that lead the "$id$" segment of its encoded strings, so
+// Verify and IsCurrent can dispatch on it without the caller naming an
+// algorithm explicitly.
+type Id string
+
+// zeroizePlaintext backs ZeroizePlaintext/SetZeroizePlaintext. It's a
+// process-wide flag read on every Key() call in argon2/pbkdf2, so it has to
+// tolerate being read concurrently with a call to SetZeroizePlaintext from
+// another goroutine.
+var zeroizePlaintext int32
+
+// ZeroizePlaintext reports whether encoder packages should overwrite the
+// plaintext password buffer they were given in place, once it's no longer
+// needed, so a caller holding a sensitive []byte doesn't have to wipe it
+// itself. Defaults to false, to preserve the historical behavior of
+// packages predating this option. Safe to call concurrently with
+// SetZeroizePlaintext.
+func ZeroizePlaintext() bool {
+	return atomic.LoadInt32(&zeroizePlaintext) != 0
+}
+
+// SetZeroizePlaintext enables or disables ZeroizePlaintext process-wide.
+// Safe to call concurrently with ZeroizePlaintext and with Generate/Verify
+// calls in flight elsewhere.
+func SetZeroizePlaintext(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&zeroizePlaintext, v)
+}
+
+var encoders = map[Id]encoder.Encoder{}
+
+// Register associates enc with id, so Generate(id, ...) produces new hashes
+// with it and Verify/IsCurrent dispatch to it for any encoded string whose
+// "$id$" segment matches id. It returns an error if id is already
+// registered, which every encoder package in this module treats as fatal
+// and panics on from its init, the same way a duplicate registration would
+// be a programming error anywhere else in the framework.
+func Register(id Id, enc encoder.Encoder) error {
+	if enc == nil {
+		return fmt.Errorf("mcf: nil encoder for id %q", id)
+	}
+	if _, exists := encoders[id]; exists {
+		return fmt.Errorf("mcf: id %q is already registered", id)
+	}
+	encoders[id] = enc
+	return nil
+}
+
+// Generate produces a new encoded password using the encoder registered
+// under id.
+func Generate(id Id, plaintext []byte) ([]byte, error) {
+	enc, ok := encoders[id]
+	if !ok {
+		return nil, fmt.Errorf("mcf: no encoder registered for id %q", id)
+	}
+	return enc.Generate(plaintext)
+}
+
+// idOf extracts the "$id$" segment leading an encoded password, which every
+// encoder in this module registers under verbatim.
+func idOf(encoded []byte) (Id, error) {
+	if len(encoded) == 0 || encoded[0] != '$' {
+		return "", fmt.Errorf("mcf: not an mcf-encoded string: %q", encoded)
+	}
+	i := bytes.IndexByte(encoded[1:], '$')
+	if i < 0 {
+		return "", fmt.Errorf("mcf: not an mcf-encoded string: %q", encoded)
+	}
+	return Id(encoded[1 : i+1]), nil
+}
+
+// Verify reports whether plaintext, encoded using the same algorithm and
+// parameters as encoded, produces encoded. The encoder is selected from
+// encoded's own "$id$" segment, so the caller never has to name one.
+func Verify(plaintext, encoded []byte) (bool, error) {
+	id, err := idOf(encoded)
+	if err != nil {
+		return false, err
+	}
+	enc, ok := encoders[id]
+	if !ok {
+		return false, fmt.Errorf("mcf: no encoder registered for id %q", id)
+	}
+	return enc.Verify(plaintext, encoded)
+}
+
+// IsCurrent reports whether encoded was produced with parameters at least as
+// good as the ones its encoder would use today. A false result means the
+// application should call Generate to replace it next time it has the
+// plaintext in hand (typically right after a successful Verify).
+func IsCurrent(encoded []byte) (bool, error) {
+	id, err := idOf(encoded)
+	if err != nil {
+		return false, err
+	}
+	enc, ok := encoders[id]
+	if !ok {
+		return false, fmt.Errorf("mcf: no encoder registered for id %q", id)
+	}
+	return enc.IsCurrent(encoded)
+}
+
+// SaltMiner is a custom source of salt bytes, used in place of crypto/rand.
+// Algorithm packages expose a package-level SaltMine var of this type so
+// tests (or callers with their own entropy source) can override it.
+type SaltMiner interface {
+	Salt(n int) ([]byte, error)
+}
+
+// Salt returns n bytes of salt from miner, or from crypto/rand if miner is
+// nil.
+func Salt(n int, miner SaltMiner) ([]byte, error) {
+	if miner != nil {
+		return miner.Salt(n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Ids for the encoders this module ships. Algorithm packages register under
+// these in their own init, so importing a package for its side effect is
+// enough to make its Id usable with Generate/Verify/IsCurrent.
+const (
+	ARGON2   Id = "argon2"
+	ARGON2ID Id = "argon2id"
+	ARGON2D  Id = "argon2d"
+	PBKDF2   Id = "pbkdf2"
+
+	// BCRYPT is "2a", the literal modular-crypt id
+	// golang.org/x/crypto/bcrypt embeds in every hash it generates, not a
+	// synthetic one — Verify/IsCurrent dispatch on a stored hash's own
+	// "$id$" segment, so this has to match it exactly. It collides with
+	// migrations.BCryptA if that package is imported too; see the bcrypt
+	// package's doc comment.
+	BCRYPT Id = "2a"
+
+	SCRYPT Id = "scrypt"
+)
@@ -0,0 +1,49 @@
+package mcf
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pzduniak/mcf/encoder"
+)
+
+type fakeEncoder struct{ id string }
+
+func (f fakeEncoder) Id() []byte                                { return []byte(f.id) }
+func (f fakeEncoder) Generate(plaintext []byte) ([]byte, error) { return plaintext, nil }
+func (f fakeEncoder) Verify(p, e []byte) (bool, error)          { return string(p) == string(e), nil }
+func (f fakeEncoder) IsCurrent(encoded []byte) (bool, error)    { return true, nil }
+
+var _ encoder.Encoder = fakeEncoder{}
+
+// TestRegisterDuplicateId covers the panic the dualFormatEncoder packages
+// (argon2, pbkdf2) now avoid: mcf.Register must reject, not silently
+// overwrite, a second registration under an id that's already taken.
+func TestRegisterDuplicateId(t *testing.T) {
+	if err := Register("mcf-test-dup", fakeEncoder{id: "mcf-test-dup"}); err != nil {
+		t.Fatalf("first Register: %v", err)
+	}
+	if err := Register("mcf-test-dup", fakeEncoder{id: "mcf-test-dup"}); err == nil {
+		t.Fatal("second Register with the same id succeeded, want an error")
+	}
+}
+
+// TestZeroizePlaintextConcurrent exercises ZeroizePlaintext/SetZeroizePlaintext
+// under the race detector: both used to be a single unsynchronized package
+// var, which go test -race flagged as a data race between a Key() reader in
+// argon2/pbkdf2 and a concurrent SetZeroizePlaintext call.
+func TestZeroizePlaintextConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func(on bool) {
+			defer wg.Done()
+			SetZeroizePlaintext(on)
+		}(i%2 == 0)
+		go func() {
+			defer wg.Done()
+			_ = ZeroizePlaintext()
+		}()
+	}
+	wg.Wait()
+}
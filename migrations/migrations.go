@@ -0,0 +1,200 @@
+// Package migrations registers mcf encoders that can verify (but never
+// generate) password hashes produced by other systems, so an existing user
+// table can be imported into mcf without forcing a password reset.
+//
+// Importing this package registers md5-crypt, bcrypt and sha256/512-crypt
+// under their modular-crypt ids automatically, exactly like argon2 or
+// pbkdf2 register their own formats. Every encoder here reports
+// IsCurrent() as false unconditionally, so the first successful
+// mcf.Verify() against an imported hash tells the application to call
+// mcf.Generate() and replace it with one of mcf's own encoders. Once every
+// user has logged in and been rehashed, this package can be dropped.
+//
+// "$pbkdf2-sha256$..." and "$2a$..." (BCryptA) are not registered
+// automatically: the pbkdf2 and bcrypt packages already register those
+// exact ids via their own RegisterPHC/init, and all three packages are
+// commonly imported together (migrate legacy hashes, then keep issuing new
+// ones with pbkdf2/bcrypt). Call RegisterLegacyPBKDF2SHA256 or
+// RegisterLegacyBCryptA explicitly only if you want this package's
+// always-legacy behavior for that id instead.
+package migrations
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/GehirnInc/crypt"
+	_ "github.com/GehirnInc/crypt/md5_crypt"
+	_ "github.com/GehirnInc/crypt/sha256_crypt"
+	_ "github.com/GehirnInc/crypt/sha512_crypt"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/pzduniak/mcf"
+	"github.com/pzduniak/mcf/encoder"
+	"github.com/pzduniak/mcf/pbkdf2"
+)
+
+// ErrNotSupported is returned by every encoder in this package from
+// Generate: they exist only to verify hashes produced elsewhere. New
+// passwords should be written with mcf.Generate and a primary encoder such
+// as argon2 or pbkdf2.
+var ErrNotSupported = errors.New("migrations: generating new hashes is not supported, use mcf.Generate with a primary encoder instead")
+
+// Modular crypt ids this package imports. They double as the mcf.Id each
+// format registers under, since a modular crypt string's own "$id$" segment
+// is already the identifier mcf.Verify dispatches on.
+const (
+	MD5Crypt     mcf.Id = "1"
+	BCryptA      mcf.Id = "2a"
+	BCryptB      mcf.Id = "2b"
+	SHA256Crypt  mcf.Id = "5"
+	SHA512Crypt  mcf.Id = "6"
+	PBKDF2SHA256 mcf.Id = "pbkdf2-sha256"
+)
+
+// cryptEncoder imports a crypt(3)/modular-crypt-format hash (md5-crypt,
+// sha256-crypt or sha512-crypt) via github.com/GehirnInc/crypt, which
+// already knows how to parse and verify each of these. bcrypt is handled
+// separately by bcryptEncoder below, since no GehirnInc/crypt driver for it
+// is imported here.
+type cryptEncoder struct {
+	id []byte
+}
+
+func (c cryptEncoder) Id() []byte {
+	return c.id
+}
+
+func (c cryptEncoder) Generate(plaintext []byte) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (c cryptEncoder) Verify(plaintext, encoded []byte) (bool, error) {
+	crypter := crypt.NewFromHash(string(encoded))
+	if crypter == nil {
+		return false, fmt.Errorf("migrations: unrecognized crypt hash for id %q", c.id)
+	}
+
+	err := crypter.Verify(string(encoded), plaintext)
+	if err == crypt.ErrKeyMismatch {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (cryptEncoder) IsCurrent(encoded []byte) (bool, error) {
+	return false, nil
+}
+
+var _ encoder.Encoder = cryptEncoder{}
+
+// bcryptEncoder imports a "$2a$"/"$2b$" bcrypt hash using
+// golang.org/x/crypto/bcrypt directly, since that package already parses
+// and compares a full modular-crypt bcrypt string and no
+// github.com/GehirnInc/crypt driver for bcrypt is imported here.
+type bcryptEncoder struct {
+	id []byte
+}
+
+func (b bcryptEncoder) Id() []byte {
+	return b.id
+}
+
+func (b bcryptEncoder) Generate(plaintext []byte) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (b bcryptEncoder) Verify(plaintext, encoded []byte) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(encoded, plaintext)
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (bcryptEncoder) IsCurrent(encoded []byte) (bool, error) {
+	return false, nil
+}
+
+var _ encoder.Encoder = bcryptEncoder{}
+
+// pbkdf2PHCImporter imports passlib's "$pbkdf2-sha256$..." PHC strings by
+// delegating the actual key derivation to the pbkdf2 package, but always
+// reports IsCurrent() as false. It is not registered automatically; see
+// RegisterLegacyPBKDF2SHA256.
+type pbkdf2PHCImporter struct{}
+
+func (pbkdf2PHCImporter) Id() []byte {
+	return []byte(PBKDF2SHA256)
+}
+
+func (pbkdf2PHCImporter) Generate(plaintext []byte) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (pbkdf2PHCImporter) Verify(plaintext, encoded []byte) (bool, error) {
+	c, salt, key, err := pbkdf2.UnmarshalPHC(string(encoded))
+	if err != nil {
+		return false, err
+	}
+
+	candidate, err := c.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+
+	return len(candidate) == len(key) && subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (pbkdf2PHCImporter) IsCurrent(encoded []byte) (bool, error) {
+	return false, nil
+}
+
+var _ encoder.Encoder = pbkdf2PHCImporter{}
+
+func init() {
+	for _, id := range []mcf.Id{MD5Crypt, SHA256Crypt, SHA512Crypt} {
+		if err := mcf.Register(id, cryptEncoder{id: []byte(id)}); err != nil {
+			panic(err)
+		}
+	}
+
+	// BCryptA ("2a") is skipped here; see RegisterLegacyBCryptA.
+	if err := mcf.Register(BCryptB, bcryptEncoder{id: []byte(BCryptB)}); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterLegacyBCryptA registers this package's "$2a$..." importer, which
+// always reports IsCurrent() as false. It is not called automatically from
+// init because mcf.BCRYPT is also "2a", and the bcrypt package is commonly
+// imported alongside this one (migrate legacy hashes, then keep issuing new
+// ones with bcrypt): auto-registering here would make mcf.Register return
+// an error for whichever of the two packages initializes second.
+//
+// Call this only in a program that does not also import bcrypt, so every
+// "$2a$..." hash is always treated as legacy and rehashed with a different
+// encoder on next login. Calling it alongside an active bcrypt registration
+// for the same id returns an error rather than silently replacing it.
+func RegisterLegacyBCryptA() error {
+	return mcf.Register(BCryptA, bcryptEncoder{id: []byte(BCryptA)})
+}
+
+// RegisterLegacyPBKDF2SHA256 registers this package's "$pbkdf2-sha256$..."
+// importer, which always reports IsCurrent() as false. It is not called
+// automatically from init because the pbkdf2 package's own RegisterPHC
+// already claims the same id, and the two packages are commonly imported
+// together (migrate legacy hashes, then keep issuing new ones with
+// pbkdf2): auto-registering here would make mcf.Register return an error
+// for whichever of the two packages initializes second.
+//
+// Call this only in a program that does not also import pbkdf2 (or that
+// imports it but never calls pbkdf2.RegisterPHC for "pbkdf2-sha256"), so
+// every "$pbkdf2-sha256$..." hash is always treated as legacy and rehashed
+// with a different encoder on next login. Calling it alongside an active
+// pbkdf2 registration for the same id returns an error rather than
+// silently replacing it.
+func RegisterLegacyPBKDF2SHA256() error {
+	return mcf.Register(PBKDF2SHA256, pbkdf2PHCImporter{})
+}
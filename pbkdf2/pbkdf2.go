@@ -9,9 +9,13 @@ import (
 	_ "crypto/sha256"
 	_ "crypto/sha512"
 	"fmt"
+	"runtime"
+	"strings"
+	"time"
 
-	"github.com/gyepisam/mcf"
-	"github.com/gyepisam/mcf/bridge"
+	"github.com/pzduniak/mcf"
+	"github.com/pzduniak/mcf/bridge"
+	"github.com/pzduniak/mcf/encoder"
 )
 
 // Hash represents the HMAC hash function that the PBKDF2 algorithm uses as a pseudorandom function.
@@ -62,8 +66,22 @@ type Config struct {
 	//Size of salt in bytes.
 	//The RFC recommends at least 8 bytes.
 	SaltLen int
+
+	// Encoding controls how the bridge renders the salt/key bytes in the
+	// final MCF string. Defaults to DefaultEncoding.
+	Encoding bridge.Encoding
+
+	// MeasuredLatency is the median single-Key() latency Tune observed while
+	// producing this Config. It is informational only, is not part of
+	// Params()/SetParams(), and is zero on a Config that wasn't built by Tune.
+	MeasuredLatency time.Duration
 }
 
+// DefaultEncoding preserves this package's historical hex-encoded salt/key
+// fields. Set Config.Encoding to bridge.Base64NoPad and friends to
+// interoperate with tools that expect a different layout.
+const DefaultEncoding = bridge.Hex
+
 // Default values. These are exported for documentation purposes.
 // See GetConfig() and SetConfig() on how to change them.
 const (
@@ -88,6 +106,7 @@ func GetConfig() Config {
 		SaltLen:    DefaultSaltLen,
 		Hash:       DefaultPrf,
 		KeyLen:     DefaultKeyLen,
+		Encoding:   DefaultEncoding,
 	}
 }
 
@@ -129,7 +148,12 @@ func register(config Config) error {
 	}
 
 	// use a the bridge to handle the generic parts of the interface
-	enc := bridge.New([]byte("pbkdf2"), fn)
+	var enc encoder.Encoder = bridge.New([]byte("pbkdf2"), fn)
+
+	// "pbkdf2" is also the PHC id for the SHA1 variant (see phc.go), so this
+	// one registration has to accept either of this package's wire formats;
+	// mcf.Register only allows one encoder per id.
+	enc = dualFormatEncoder{native: enc, phc: phcCodec{id: "pbkdf2"}}
 
 	return mcf.Register(mcf.PBKDF2, enc)
 }
@@ -156,20 +180,31 @@ func (c *Config) validate() error {
 }
 
 // Keep these together
-//Note that Sscanf on %s breaks on space and must therefore be the last item (and the only string).
-const format = "keylen=%d,iterations=%d,hmac=%s"
+// Note that Sscanf on %s breaks on space and must therefore be the last item
+// (and the only string). Hash and Encoding are packed into that single
+// trailing field, comma-separated, and split out by hand below.
+const format = "keylen=%d,iterations=%d,he=%s"
 
 // Params encodes algorithm parameters in a string for later use.
 func (c *Config) Params() string {
-	return fmt.Sprintf(format, c.KeyLen, c.Iterations, c.Hash)
+	return fmt.Sprintf(format, c.KeyLen, c.Iterations, fmt.Sprintf("%s,%s", c.Hash, c.Encoding))
 }
 
 // SetParams extracts encoded algorithm parameters from the output of Params().
 func (c *Config) SetParams(params string) error {
-	_, err := fmt.Sscanf(params, format, &c.KeyLen, &c.Iterations, &c.Hash)
+	var he string
+	_, err := fmt.Sscanf(params, format, &c.KeyLen, &c.Iterations, &he)
 	if err != nil {
 		return err
 	}
+
+	parts := strings.SplitN(he, ",", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("pbkdf2: malformed hash/encoding field: %q", he)
+	}
+	c.Hash = Hash(parts[0])
+	c.Encoding = bridge.Encoding(parts[1])
+
 	return c.validate()
 }
 
@@ -178,9 +213,23 @@ func (c *Config) Salt() ([]byte, error) {
 	return mcf.Salt(c.SaltLen, SaltMine)
 }
 
+// EncodingMode reports how the bridge should render this Config's salt and
+// key bytes; see bridge.Implementer.EncodingMode for why it's read per-instance.
+func (c *Config) EncodingMode() bridge.Encoding {
+	return c.Encoding
+}
+
 // Key generates a PBKDF2 key from the password, salt and iteration count, using the Hash as a pseudorandom function.
+// If mcf.ZeroizePlaintext() is set, password is wiped before Key returns.
 func (c *Config) Key(password, salt []byte) ([]byte, error) {
-	return pbkdf2.Key(password, salt, c.Iterations, c.KeyLen, hashes[c.Hash].New), nil
+	key := pbkdf2.Key(password, salt, c.Iterations, c.KeyLen, hashes[c.Hash].New)
+
+	if mcf.ZeroizePlaintext() {
+		bridge.Wipe(password)
+	}
+	runtime.KeepAlive(password)
+
+	return key, nil
 }
 
 // AtLeast compares the parameters for an encoded password to the current configuration
@@ -189,4 +238,4 @@ func (c *Config) Key(password, salt []byte) ([]byte, error) {
 func (c *Config) AtLeast(current_imp bridge.Implementer) bool {
 	current := current_imp.(*Config) // ok to panic if this fails.
 	return !(c.Iterations < current.Iterations || c.KeyLen < current.KeyLen || c.SaltLen < current.SaltLen)
-}
\ No newline at end of file
+}
@@ -0,0 +1,61 @@
+package pbkdf2
+
+import "testing"
+
+// TestSHA1PHCRoundTrip covers the regression that motivated
+// dualFormatEncoder: the SHA1 variant's PHC id ("pbkdf2") is identical to
+// this package's bridge-native id, so a naive two-call mcf.Register would
+// panic on init.
+func TestSHA1PHCRoundTrip(t *testing.T) {
+	c := GetConfig()
+	c.Hash = SHA1
+
+	salt, err := c.Salt()
+	if err != nil {
+		t.Fatalf("Salt: %v", err)
+	}
+	key, err := c.Key([]byte("hunter2"), salt)
+	if err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+
+	phc, err := c.MarshalPHC(salt, key)
+	if err != nil {
+		t.Fatalf("MarshalPHC: %v", err)
+	}
+
+	if !isPHCEncoded([]byte(phc)) {
+		t.Fatal("isPHCEncoded didn't recognize a string produced by MarshalPHC")
+	}
+
+	codec := phcCodec{id: "pbkdf2"}
+
+	ok, err := codec.Verify([]byte("hunter2"), []byte(phc))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !ok {
+		t.Fatal("Verify returned false for a hash just generated with the same password")
+	}
+
+	ok, err = codec.Verify([]byte("wrong"), []byte(phc))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if ok {
+		t.Fatal("Verify returned true for the wrong password")
+	}
+}
+
+// TestNativeFormatNotMistakenForPHC covers the other direction of the same
+// dispatch: a hash in this package's historical bridge-native format must
+// not be mistaken for a PHC string.
+func TestNativeFormatNotMistakenForPHC(t *testing.T) {
+	c := GetConfig()
+	c.Hash = SHA1
+
+	native := "$pbkdf2$" + c.Params() + "$deadbeef$deadbeef"
+	if isPHCEncoded([]byte(native)) {
+		t.Fatal("isPHCEncoded mistook a bridge-native string for PHC")
+	}
+}
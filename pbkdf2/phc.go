@@ -0,0 +1,223 @@
+package pbkdf2
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pzduniak/mcf"
+	"github.com/pzduniak/mcf/bridge"
+	"github.com/pzduniak/mcf/encoder"
+)
+
+// phcIds maps this package's Hash values to the PHC algorithm identifier
+// used as the leading "$id" segment of a PHC string, e.g. "pbkdf2-sha256".
+var phcIds = map[Hash]string{
+	SHA1:   "pbkdf2",
+	SHA256: "pbkdf2-sha256",
+	SHA512: "pbkdf2-sha512",
+}
+
+var phcHashes = func() map[string]Hash {
+	m := make(map[string]Hash, len(phcIds))
+	for hash, id := range phcIds {
+		m[id] = hash
+	}
+	return m
+}()
+
+var phcEncoding = base64.RawStdEncoding
+
+// MarshalPHC encodes salt and key as a PHC string
+// (https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md), e.g.
+//
+//	$pbkdf2-sha256$i=29000$<b64salt>$<b64hash>
+//
+// This is the format used by passlib, passwap, kdfcrypt and most other
+// ecosystem tools, as opposed to this package's historical Params()/SetParams()
+// layout. It returns an error if c.Hash has no known PHC identifier.
+func (c *Config) MarshalPHC(salt, key []byte) (string, error) {
+	id, ok := phcIds[c.Hash]
+	if !ok {
+		return "", ErrInvalidHash("no PHC identifier for hash: " + string(c.Hash))
+	}
+
+	return fmt.Sprintf("$%s$i=%d$%s$%s",
+		id, c.Iterations, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+// UnmarshalPHC parses a PHC-formatted pbkdf2 hash, returning the parameters
+// it encodes along with the raw salt and key bytes.
+func UnmarshalPHC(s string) (c Config, salt, key []byte, err error) {
+	parts := strings.Split(s, "$")
+	// strings.Split("$pbkdf2-sha256$...", "$") yields a leading empty element.
+	if len(parts) != 5 || parts[0] != "" {
+		return c, nil, nil, fmt.Errorf("pbkdf2: not a PHC string")
+	}
+
+	hash, ok := phcHashes[parts[1]]
+	if !ok {
+		return c, nil, nil, fmt.Errorf("pbkdf2: unrecognized PHC id: %s", parts[1])
+	}
+	c.Hash = hash
+
+	if _, err = fmt.Sscanf(parts[2], "i=%d", &c.Iterations); err != nil {
+		return c, nil, nil, err
+	}
+
+	if salt, err = phcEncoding.DecodeString(parts[3]); err != nil {
+		return c, nil, nil, err
+	}
+	if key, err = phcEncoding.DecodeString(parts[4]); err != nil {
+		return c, nil, nil, err
+	}
+
+	c.SaltLen = len(salt)
+	c.KeyLen = len(key)
+
+	return c, salt, key, nil
+}
+
+// phcCodec is an encoder.Encoder that reads and writes the PHC string format
+// on top of this package's Config, so PHC-encoded hashes can be registered
+// with mcf.Register alongside the package's historical format.
+type phcCodec struct {
+	id string
+}
+
+func (p phcCodec) Id() []byte {
+	return []byte(p.id)
+}
+
+func (p phcCodec) Generate(plaintext []byte) ([]byte, error) {
+	c := GetConfig()
+	c.Hash = phcHashes[p.id]
+
+	salt, err := c.Salt()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.Key(plaintext, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer bridge.Wipe(key)
+
+	s, err := c.MarshalPHC(salt, key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func (phcCodec) Verify(plaintext, encoded []byte) (bool, error) {
+	c, salt, key, err := UnmarshalPHC(string(encoded))
+	if err != nil {
+		return false, err
+	}
+
+	candidate, err := c.Key(plaintext, salt)
+	if err != nil {
+		return false, err
+	}
+	defer bridge.Wipe(candidate)
+
+	return len(candidate) == len(key) && subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (phcCodec) IsCurrent(encoded []byte) (bool, error) {
+	c, _, _, err := UnmarshalPHC(string(encoded))
+	if err != nil {
+		return false, err
+	}
+
+	current := GetConfig()
+	current.Hash = c.Hash
+	return c.AtLeast(&current), nil
+}
+
+var _ encoder.Encoder = phcCodec{}
+
+// RegisterPHC registers the PHC string format for id (one of "pbkdf2",
+// "pbkdf2-sha256" or "pbkdf2-sha512") under its own mcf.Id, so that
+// mcf.Generate/mcf.Verify can produce and consume
+// "$pbkdf2-sha256$i=...$<salt>$<hash>" strings interoperable with passlib,
+// passwap and kdfcrypt, in addition to this package's own format registered
+// under mcf.PBKDF2.
+// Only call this for a PHC id other than "pbkdf2": that one is also
+// pbkdf2.go's bridge-native id, and register() there already wires PHC
+// support for it in via dualFormatEncoder instead, since mcf.Register only
+// allows one encoder per id.
+func RegisterPHC(id string) error {
+	if _, ok := phcHashes[id]; !ok {
+		return fmt.Errorf("pbkdf2: unrecognized PHC id: %s", id)
+	}
+	return mcf.Register(mcf.Id(id), phcCodec{id: id})
+}
+
+func init() {
+	// "pbkdf2" (the SHA1 variant's PHC id) is skipped here: it's the exact
+	// same string as this package's bridge-native id, so mcf.Register would
+	// panic on the second, duplicate registration. pbkdf2.go's register()
+	// already gives that id dual-format support. "pbkdf2-sha256" and
+	// "pbkdf2-sha512" don't collide with anything, so they're registered
+	// directly.
+	for id := range phcHashes {
+		if id == "pbkdf2" {
+			continue
+		}
+		if err := RegisterPHC(id); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// dualFormatEncoder lets a single mcf.Id accept either this package's
+// historical bridge-native format or the PHC format. It exists because the
+// SHA1 variant's PHC id ("pbkdf2") is identical to this package's
+// bridge-native id, so only one encoder can ever be registered for it, and
+// it has to recognize both of this package's wire formats to stay
+// interoperable with both.
+type dualFormatEncoder struct {
+	native encoder.Encoder
+	phc    phcCodec
+}
+
+func (d dualFormatEncoder) Id() []byte {
+	return d.native.Id()
+}
+
+// Generate always produces this package's historical bridge-native format;
+// PHC output for the SHA1 variant isn't reachable via mcf.Generate at all,
+// same as it never was before PHC support existed, since there's no
+// non-colliding id to register it under.
+func (d dualFormatEncoder) Generate(plaintext []byte) ([]byte, error) {
+	return d.native.Generate(plaintext)
+}
+
+// isPHCEncoded tells the PHC format ("$pbkdf2$i=...$salt$key") apart from
+// this package's bridge-native format ("$pbkdf2$keylen=...,...$salt$key").
+// Both have the same number of "$"-delimited fields, so this looks at the
+// params field's content instead of counting them.
+func isPHCEncoded(encoded []byte) bool {
+	parts := strings.SplitN(string(encoded), "$", 4)
+	return len(parts) >= 3 && strings.HasPrefix(parts[2], "i=")
+}
+
+func (d dualFormatEncoder) Verify(plaintext, encoded []byte) (bool, error) {
+	if isPHCEncoded(encoded) {
+		return d.phc.Verify(plaintext, encoded)
+	}
+	return d.native.Verify(plaintext, encoded)
+}
+
+func (d dualFormatEncoder) IsCurrent(encoded []byte) (bool, error) {
+	if isPHCEncoded(encoded) {
+		return d.phc.IsCurrent(encoded)
+	}
+	return d.native.IsCurrent(encoded)
+}
+
+var _ encoder.Encoder = dualFormatEncoder{}
@@ -0,0 +1,92 @@
+package pbkdf2
+
+import (
+	"sort"
+	"time"
+)
+
+// Suggested targets for Tune. Interactive logins should feel instant;
+// batch/offline verification (e.g. importing a password database) can
+// afford to spend much longer per check.
+const (
+	DefaultInteractiveTarget = 250 * time.Millisecond
+	DefaultBatchTarget       = time.Second
+)
+
+// tuneSamples is how many probes Tune takes at each candidate Iterations
+// value; the median of these is used to dampen scheduler noise.
+const tuneSamples = 5
+
+// Tune benchmarks Config.Key on the current machine and returns a Config
+// whose Iterations make a single password check take about target.
+// Iterations is grown by doubling until target is reached, then bisected
+// against the last two probed values to land close to it. The returned
+// Config's MeasuredLatency field records the latency actually observed.
+func Tune(target time.Duration) (Config, error) {
+	c := GetConfig()
+
+	lo, hi := 0, c.Iterations
+	if hi < 1 {
+		hi = 1
+	}
+	c.Iterations = hi
+
+	latency, err := medianLatency(&c, tuneSamples)
+	if err != nil {
+		return c, err
+	}
+
+	for latency < target {
+		lo = hi
+		hi *= 2
+		c.Iterations = hi
+
+		latency, err = medianLatency(&c, tuneSamples)
+		if err != nil {
+			return c, err
+		}
+	}
+
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		c.Iterations = mid
+
+		latency, err = medianLatency(&c, tuneSamples)
+		if err != nil {
+			return c, err
+		}
+
+		if latency < target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	c.Iterations = hi
+
+	latency, err = medianLatency(&c, tuneSamples)
+	if err != nil {
+		return c, err
+	}
+	c.MeasuredLatency = latency
+
+	return c, nil
+}
+
+// medianLatency runs c.Key samples times against a fixed password/salt and
+// returns the median wall-clock duration of a single call.
+func medianLatency(c *Config, samples int) (time.Duration, error) {
+	salt := make([]byte, c.SaltLen)
+	durations := make([]time.Duration, 0, samples)
+
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		if _, err := c.Key([]byte("password"), salt); err != nil {
+			return 0, err
+		}
+		durations = append(durations, time.Since(start))
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations[len(durations)/2], nil
+}
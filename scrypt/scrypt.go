@@ -0,0 +1,145 @@
+// Package scrypt implements a password encoding mechanism for the mcf framework.
+package scrypt
+
+import (
+	"golang.org/x/crypto/scrypt"
+
+	"fmt"
+
+	"github.com/pzduniak/mcf"
+	"github.com/pzduniak/mcf/bridge"
+)
+
+// Around 15ms per check on modern low-range server hardware
+const (
+	DefaultKeyLen  = 32
+	DefaultSaltLen = 16
+
+	DefaultN = 16384
+	DefaultR = 8
+	DefaultP = 1
+)
+
+// Config contains the scrypt algorithm parameters and other associated values.
+// Use the GetConfig() and SetConfig() combination to change any desired parameters.
+type Config struct {
+	KeyLen  int // Key output size in bytes
+	SaltLen int // Length of salt in bytes
+
+	N int // CPU/memory cost, must be a power of 2
+	R int // Block size
+	P int // Parallelization
+}
+
+// Custom source of salt, normally unset.
+// Set this if you need to override the user of rand.Reader and
+// use a custom salt producer.
+// Also useful for testing.
+var SaltMine mcf.SaltMiner = nil
+
+// ErrInvalidParameter is returned by SetConfig if any of the provided parameters
+// fail validation. The error message contains the name and value of the faulty
+// parameter to aid in resolving the problem.
+type ErrInvalidParameter struct {
+	Name  string
+	Value int
+}
+
+func (e ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("parameter %s has invalid value: %d", e.Name, e.Value)
+}
+
+// GetConfig returns the default configuration used to create new scrypt password hashes.
+// The return value can be modified and used as a parameter to SetConfig.
+func GetConfig() Config {
+	return Config{
+		KeyLen:  DefaultKeyLen,
+		SaltLen: DefaultSaltLen,
+		N:       DefaultN,
+		R:       DefaultR,
+		P:       DefaultP,
+	}
+}
+
+// SetConfig sets the default encoding parameters, salt length or key length.
+// It is best to modify a copy of the default configuration unless all parameters are changed.
+//
+//	config := scrypt.GetConfig()
+//	config.N *= 2
+//	scrypt.SetConfig(config)
+func SetConfig(config Config) error {
+	c := &config
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+
+	return register(config)
+}
+
+func register(config Config) error {
+	// Constructor function. Provide fresh copy each time.
+	fn := func() bridge.Implementer {
+		c := config
+		return &c
+	}
+
+	enc := bridge.New([]byte("scrypt"), fn)
+
+	return mcf.Register(mcf.SCRYPT, enc)
+}
+
+func init() {
+	err := register(GetConfig())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *Config) validate() error {
+	//punt, cheat and see if the underlying algorithm complains!
+	_, err := c.Key([]byte("password"), []byte("saltsaltsaltsalt"))
+	return err
+}
+
+// Keep these together.
+var format = "KeyLen=%d,N=%d,R=%d,P=%d"
+
+// Params returns the current digest algorithm parameters.
+func (c *Config) Params() string {
+	return fmt.Sprintf(format, c.KeyLen, c.N, c.R, c.P)
+}
+
+// SetParams sets the parameters for the digest algorithm.
+func (c *Config) SetParams(s string) error {
+	_, err := fmt.Sscanf(s, format, &c.KeyLen, &c.N, &c.R, &c.P)
+	if err != nil {
+		return err
+	}
+	return c.validate()
+}
+
+// Salt produces SaltLen bytes of random data.
+func (c *Config) Salt() ([]byte, error) {
+	return mcf.Salt(c.SaltLen, SaltMine)
+}
+
+// EncodingMode reports how the bridge should render this Config's salt and
+// key bytes. This package has never needed a configurable Encoding, so it
+// always renders hex, same as before bridge.Encoding existed.
+func (c *Config) EncodingMode() bridge.Encoding {
+	return bridge.Hex
+}
+
+// Key returns an scrypt digest of plaintext and salt using the algorithm parameters: N, r, and p.
+// The returned value is of length KeyLen.
+func (c *Config) Key(plaintext []byte, salt []byte) (b []byte, err error) {
+	return scrypt.Key(plaintext, salt, c.N, c.R, c.P, c.KeyLen)
+}
+
+// AtLeast returns true if the parameters used to generate the encoded password
+// are at least as good as those currently in use.
+func (c *Config) AtLeast(current_imp bridge.Implementer) bool {
+	current := current_imp.(*Config) // ok to panic
+	return !(c.N < current.N || c.R < current.R || c.P < current.P || c.KeyLen < current.KeyLen)
+}